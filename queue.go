@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// queuedTask is a single submission to the queued execution mode, waiting
+// for or being handled by a worker.
+type queuedTask struct {
+	id     string
+	job    *JobConfig
+	params map[string]string
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var (
+	taskQueue chan *queuedTask
+	queueWG   sync.WaitGroup
+
+	// taskCancel holds the cancel func for every task that hasn't finished
+	// yet (queued or running), keyed by run ID, guarded by mu.
+	taskCancel map[string]context.CancelFunc
+)
+
+// startQueueWorkers launches n workers that drain taskQueue in FIFO order,
+// running at most n commands concurrently.
+func startQueueWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	mu.Lock()
+	taskCancel = make(map[string]context.CancelFunc)
+	mu.Unlock()
+
+	taskQueue = make(chan *queuedTask, 1024)
+	for i := 0; i < n; i++ {
+		queueWG.Add(1)
+		go func() {
+			defer queueWG.Done()
+			for task := range taskQueue {
+				runQueuedTask(task)
+			}
+		}()
+	}
+}
+
+// enqueueTask records a new run in the queued state and submits it to the
+// worker pool, returning the run so the caller can report its ID immediately.
+func enqueueTask(job *JobConfig, params map[string]string) *runRecord {
+	run := newRunRecord(job.Name, defaultMaxRunOutputSize)
+	run.markQueued()
+	runs.add(run)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mu.Lock()
+	taskCancel[run.ID] = cancel
+	mu.Unlock()
+
+	taskQueue <- &queuedTask{id: run.ID, job: job, params: params, ctx: ctx, cancel: cancel}
+	return run
+}
+
+// cancelTask cancels a queued or running task by run ID. It reports false if
+// no such task is tracked, e.g. because it already finished.
+func cancelTask(id string) bool {
+	mu.Lock()
+	cancel, ok := taskCancel[id]
+	mu.Unlock()
+	if !ok {
+		return false
+	}
+	cancel()
+	return true
+}
+
+// runQueuedTask runs a single queued task to completion, honoring
+// cancellation via task.ctx both before the command starts and while it is
+// running.
+func runQueuedTask(task *queuedTask) {
+	defer func() {
+		task.cancel()
+		mu.Lock()
+		delete(taskCancel, task.id)
+		mu.Unlock()
+	}()
+
+	run, ok := runs.get(task.id)
+	if !ok {
+		return
+	}
+
+	if task.ctx.Err() != nil {
+		run.finish(-1)
+		runs.persist(run)
+		return
+	}
+
+	cmd, stdout, stderr, err := startCommand(task.job, task.params)
+	if err != nil {
+		run.markRunning()
+		run.finish(1)
+		runs.persist(run)
+		return
+	}
+
+	run.markRunning()
+	inFlight.Add(1)
+	defer inFlight.Done()
+	registerCmd(run.ID, cmd)
+	defer unregisterCmd(run.ID)
+
+	watchDone := make(chan struct{})
+	go func() {
+		select {
+		case <-task.ctx.Done():
+			killCmd(cmd)
+		case <-watchDone:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); io.Copy(io.MultiWriter(os.Stdout, run), stdout) }()
+	go func() { defer wg.Done(); io.Copy(io.MultiWriter(os.Stderr, run), stderr) }()
+
+	wg.Wait()
+	waitErr := cmd.Wait()
+	close(watchDone)
+
+	exitCode := 0
+	if waitErr != nil {
+		if exitError, ok := waitErr.(*exec.ExitError); ok {
+			exitCode = exitError.ExitCode()
+		} else {
+			exitCode = 1
+		}
+	}
+	run.finish(exitCode)
+	runs.persist(run)
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -10,26 +10,78 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
+	"github.com/kelseyhightower/envconfig"
 	"gopkg.in/yaml.v2"
 )
 
-// Config struct to hold the command configuration
-type Config struct {
+const (
+	defaultMaxRunOutputSize = 1 << 20 // 1 MiB
+	envPrefix               = "RCS"
+)
+
+// Settings holds every environment-configurable setting for the service,
+// loaded in one pass by envconfig from variables prefixed with "RCS_".
+type Settings struct {
+	ConfigFilePath  string `envconfig:"CONFIG_FILE_PATH"`
+	ExecuteSecret   string `envconfig:"EXECUTE_SECRET" required:"true"`
+	ShellPath       string `envconfig:"SHELL_PATH" default:"/bin/sh"`
+	ListenPort      string `envconfig:"LISTEN_PORT" default:"8080"`
+	LogFormat       string `envconfig:"LOG_FORMAT" default:"text"`
+	MaxRunsRetained int    `envconfig:"MAX_RUNS_RETAINED" default:"100"`
+	RunsPersistDir  string `envconfig:"RUNS_PERSIST_DIR"`
+	// MaxConcurrentRuns bounds how many jobs the queued execution mode runs at once.
+	MaxConcurrentRuns int      `envconfig:"MAX_CONCURRENT_RUNS" default:"0"`
+	CORSOrigins       []string `envconfig:"CORS_ORIGINS"`
+	TLSCertFile       string   `envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile        string   `envconfig:"TLS_KEY_FILE"`
+	// ShutdownGracePeriod bounds how long shutdown waits for in-flight commands
+	// to finish on their own before signaling them.
+	ShutdownGracePeriod time.Duration `envconfig:"SHUTDOWN_GRACE_PERIOD" default:"20s"`
+	// ShutdownKillTimeout bounds how long shutdown waits after signaling
+	// commands before killing them outright.
+	ShutdownKillTimeout time.Duration `envconfig:"SHUTDOWN_KILL_TIMEOUT" default:"5s"`
+}
+
+// JobConfig describes a single named job that can be triggered via the API.
+type JobConfig struct {
+	Name            string `yaml:"name"`
 	Command         string `yaml:"command"`
 	RunInBackground bool   `yaml:"runInBackground"`
 	RunOnce         bool   `yaml:"runOnce"`
+	// Queued jobs are always accepted immediately and run FIFO by a bounded
+	// worker pool, instead of running inline or being rejected while busy.
+	Queued     bool              `yaml:"queued"`
+	Args       []string          `yaml:"args"`
+	Secret     string            `yaml:"secret"`
+	WorkingDir string            `yaml:"workingDir"`
+	Env        map[string]string `yaml:"env"`
+}
+
+// Config struct to hold the jobs configuration
+type Config struct {
+	Jobs []JobConfig `yaml:"jobs"`
 }
 
 var (
-	config        Config
-	executeSecret string
-	shellPath     string
-	listenPort    string
-	mu            sync.Mutex
-	isRunning     bool
+	config      Config
+	jobsByName  map[string]*JobConfig
+	settings    Settings
+	mu          sync.Mutex
+	runningJobs map[string]bool
+	runs        *runStore
+
+	// activeCmds and shuttingDown are guarded by mu. inFlight tracks every
+	// command currently executing so shutdown can wait for it to drain.
+	activeCmds   map[string]*exec.Cmd
+	shuttingDown bool
+	inFlight     sync.WaitGroup
 )
 
 func main() {
@@ -51,8 +103,19 @@ func main() {
 func run() error {
 	log.Println("Starting Run Command Service")
 
+	if err := envconfig.Process(envPrefix, &settings); err != nil {
+		return fmt.Errorf("error loading settings: %v", err)
+	}
+	configureLogging(settings.LogFormat)
+
+	runningJobs = make(map[string]bool)
+	mu.Lock()
+	activeCmds = make(map[string]*exec.Cmd)
+	shuttingDown = false
+	mu.Unlock()
+
 	// Load configuration from file
-	configPath := os.Getenv("CONFIG_FILE_PATH")
+	configPath := settings.ConfigFilePath
 	if configPath == "" {
 		// Set default config path to "config.yaml" in the same directory as the executable
 		ex, err := os.Executable()
@@ -60,7 +123,7 @@ func run() error {
 			return fmt.Errorf("error getting executable path: %v", err)
 		}
 		configPath = filepath.Join(filepath.Dir(ex), "config.yaml")
-		log.Printf("CONFIG_FILE_PATH not set, using default: %s", configPath)
+		log.Printf("RCS_CONFIG_FILE_PATH not set, using default: %s", configPath)
 	}
 
 	configFile, err := os.ReadFile(configPath)
@@ -73,71 +136,358 @@ func run() error {
 		return fmt.Errorf("error parsing config file: %v", err)
 	}
 
-	if config.RunOnce && config.RunInBackground {
-		return errors.New("runOnce and runInBackground cannot both be set to true")
+	jobsByName = make(map[string]*JobConfig, len(config.Jobs))
+	allRunOnce := len(config.Jobs) > 0
+	for i := range config.Jobs {
+		job := &config.Jobs[i]
+		if job.Name == "" {
+			return fmt.Errorf("job at index %d has no name", i)
+		}
+		if _, exists := jobsByName[job.Name]; exists {
+			return fmt.Errorf("duplicate job name %q", job.Name)
+		}
+		exclusiveModes := 0
+		for _, set := range []bool{job.RunOnce, job.RunInBackground, job.Queued} {
+			if set {
+				exclusiveModes++
+			}
+		}
+		if exclusiveModes > 1 {
+			return fmt.Errorf("job %q: runOnce, runInBackground, and queued are mutually exclusive", job.Name)
+		}
+		jobsByName[job.Name] = job
+		if !job.RunOnce {
+			allRunOnce = false
+		}
 	}
 
-	// Get execute secret from environment variable
-	executeSecret = os.Getenv("EXECUTE_SECRET")
-	if executeSecret == "" {
-		return fmt.Errorf("EXECUTE_SECRET environment variable is not set")
+	runs = newRunStore(settings.MaxRunsRetained, settings.RunsPersistDir)
+	if settings.RunsPersistDir != "" {
+		log.Printf("Loading run history from %s", settings.RunsPersistDir)
+		runs.loadFromDisk()
 	}
 
-	// Get shell path from environment variable or use default
-	shellPath = os.Getenv("SHELL_PATH")
-	if shellPath == "" {
-		shellPath = "/bin/sh"
-		log.Println("SHELL_PATH not set, defaulting to /bin/sh")
+	for _, job := range config.Jobs {
+		if job.Queued {
+			startQueueWorkers(settings.MaxConcurrentRuns)
+			break
+		}
 	}
 
-	// Get listen port from environment variable or use default
-	listenPort = os.Getenv("LISTEN_PORT")
-	if listenPort == "" {
-		listenPort = "8080"
-		log.Println("LISTEN_PORT not set, defaulting to 8080")
+	// Run any jobs marked runOnce immediately, in declaration order
+	for i := range config.Jobs {
+		job := &config.Jobs[i]
+		if !job.RunOnce {
+			continue
+		}
+		log.Printf("Running job %q once at startup", job.Name)
+		if err := executeCommand(job, nil); err != nil {
+			return fmt.Errorf("job %q failed: %v", job.Name, err)
+		}
 	}
 
-	// Print the command that will be executed
-	expandedCommand := os.ExpandEnv(config.Command)
-	log.Println("Command that will be executed:")
-	log.Println("----------------------------------------")
-	log.Println(expandedCommand)
-	log.Println("----------------------------------------")
-
-	// If RunOnce is true, execute the command and exit
-	if config.RunOnce {
-		return executeCommand(expandedCommand)
+	// If every configured job is runOnce, there is nothing left to serve
+	if allRunOnce {
+		return nil
 	}
 
 	// Set up HTTP server
 	http.HandleFunc("/ready", readyHandler)
-	http.HandleFunc("/execute", executeHandler)
+	http.HandleFunc("/jobs", jobsHandler)
+	http.HandleFunc("/execute/", executeHandler)
+	http.HandleFunc("/runs", runsListHandler)
+	http.HandleFunc("/runs/", runsItemHandler)
+
+	var handler http.Handler = http.DefaultServeMux
+	if len(settings.CORSOrigins) > 0 {
+		handler = corsMiddleware(handler, settings.CORSOrigins)
+	}
+
+	srv := &http.Server{Addr: ":" + settings.ListenPort, Handler: handler}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	serveErrCh := make(chan error, 1)
+	go func() {
+		log.Printf("Run Command Service starting on :%s", settings.ListenPort)
+		if settings.TLSCertFile != "" || settings.TLSKeyFile != "" {
+			serveErrCh <- srv.ListenAndServeTLS(settings.TLSCertFile, settings.TLSKeyFile)
+		} else {
+			serveErrCh <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErrCh:
+		if err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	case sig := <-sigCh:
+		return gracefulShutdown(sig, srv, settings.ShutdownGracePeriod, settings.ShutdownKillTimeout)
+	}
+}
+
+// gracefulShutdown stops the HTTP server from accepting new connections,
+// waits up to gracePeriod for in-flight commands to finish on their own,
+// then forwards sig to them and waits up to killTimeout more before killing
+// them outright. /ready reports 503 for the whole duration via shuttingDown.
+func gracefulShutdown(sig os.Signal, srv *http.Server, gracePeriod, killTimeout time.Duration) error {
+	log.Printf("Received %s, starting graceful shutdown", sig)
+
+	mu.Lock()
+	shuttingDown = true
+	mu.Unlock()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("error shutting down HTTP server: %v", err)
+	}
+
+	doneCh := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+		log.Println("all in-flight commands finished")
+		return nil
+	case <-time.After(gracePeriod):
+	}
+
+	log.Printf("grace period elapsed, sending %s to running commands", sig)
+	signalActiveCmds(sig)
+
+	select {
+	case <-doneCh:
+		return nil
+	case <-time.After(killTimeout):
+	}
+
+	log.Println("kill timeout elapsed, killing remaining commands")
+	killActiveCmds()
+	<-doneCh
+	return nil
+}
+
+// registerCmd and unregisterCmd track the processes currently executing so
+// shutdown can signal or kill them.
+func registerCmd(id string, cmd *exec.Cmd) {
+	mu.Lock()
+	activeCmds[id] = cmd
+	mu.Unlock()
+}
+
+func unregisterCmd(id string) {
+	mu.Lock()
+	delete(activeCmds, id)
+	mu.Unlock()
+}
+
+func signalActiveCmds(sig os.Signal) {
+	sysSig, ok := sig.(syscall.Signal)
+	for _, cmd := range snapshotActiveCmds() {
+		if cmd.Process == nil {
+			continue
+		}
+		if !ok || syscall.Kill(-cmd.Process.Pid, sysSig) != nil {
+			_ = cmd.Process.Signal(sig)
+		}
+	}
+}
+
+func killActiveCmds() {
+	for _, cmd := range snapshotActiveCmds() {
+		killCmd(cmd)
+	}
+}
+
+// killCmd kills cmd's whole process group so a shell and the children it
+// spawned are all terminated, falling back to killing just the direct
+// child if the process group kill fails.
+func killCmd(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+func snapshotActiveCmds() []*exec.Cmd {
+	mu.Lock()
+	defer mu.Unlock()
+	cmds := make([]*exec.Cmd, 0, len(activeCmds))
+	for _, cmd := range activeCmds {
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// corsMiddleware sets CORS headers for the configured allowed origins before
+// delegating to next, answering preflight OPTIONS requests directly.
+func corsMiddleware(next http.Handler, allowedOrigins []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || allowed == origin {
+				w.Header().Set("Access-Control-Allow-Origin", allowed)
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, x-secret")
+				break
+			}
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// configureLogging switches the standard logger to single-line JSON output
+// when format is "json"; any other value (including the default "text")
+// leaves the standard logger untouched.
+func configureLogging(format string) {
+	if format != "json" {
+		return
+	}
+	log.SetFlags(0)
+	log.SetOutput(jsonLogWriter{out: os.Stderr})
+}
+
+type jsonLogWriter struct {
+	out io.Writer
+}
+
+func (w jsonLogWriter) Write(p []byte) (int, error) {
+	line, err := json.Marshal(map[string]string{"msg": strings.TrimRight(string(p), "\n")})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.out.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// shellQuote wraps s in single quotes so it is safe to splice into a shell
+// command as one literal argument, regardless of any metacharacters it
+// contains, escaping embedded single quotes along the way.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// expandCommand expands ${VAR} placeholders in a job's command, preferring
+// user-supplied params over the process environment. Param values are
+// shell-quoted since, unlike the process environment, they come from the
+// caller of POST /execute and must not be able to inject shell syntax.
+func expandCommand(job *JobConfig, params map[string]string) string {
+	return os.Expand(job.Command, func(key string) string {
+		if v, ok := params[key]; ok {
+			return shellQuote(v)
+		}
+		return os.Getenv(key)
+	})
+}
 
-	log.Printf("Run Command Service starting on :%s", listenPort)
-	return http.ListenAndServe(":"+listenPort, nil)
+// validateParams rejects any param not declared in the job's Args allow-list.
+// A job that declares no args accepts no params at all.
+func validateParams(job *JobConfig, params map[string]string) error {
+	if len(params) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool, len(job.Args))
+	for _, a := range job.Args {
+		allowed[a] = true
+	}
+	for k := range params {
+		if !allowed[k] {
+			return fmt.Errorf("unexpected parameter %q", k)
+		}
+	}
+	return nil
 }
 
-func executeCommand(command string) error {
-	cmd := exec.Command(shellPath, "-c", command)
+// startCommand builds and starts the process for a job, wiring up stdout/stderr pipes.
+func startCommand(job *JobConfig, params map[string]string) (*exec.Cmd, io.ReadCloser, io.ReadCloser, error) {
+	if err := validateParams(job, params); err != nil {
+		return nil, nil, nil, err
+	}
+
+	expandedCommand := expandCommand(job, params)
+	cmd := exec.Command(settings.ShellPath, "-c", expandedCommand)
+	// Run in its own process group so shutdown can signal the whole group,
+	// not just the shell, and doesn't leave orphaned children behind.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if job.WorkingDir != "" {
+		cmd.Dir = job.WorkingDir
+	}
+	if len(job.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range job.Env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("error creating stdout pipe: %v", err)
+		return nil, nil, nil, fmt.Errorf("error creating stdout pipe: %v", err)
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("error creating stderr pipe: %v", err)
+		return nil, nil, nil, fmt.Errorf("error creating stderr pipe: %v", err)
 	}
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("error starting command: %v", err)
+		return nil, nil, nil, fmt.Errorf("error starting command: %v", err)
+	}
+
+	return cmd, stdout, stderr, nil
+}
+
+// executeCommand runs a job's command to completion, streaming its output to
+// our own stdout/stderr as well as into a run record for later inspection.
+func executeCommand(job *JobConfig, params map[string]string) error {
+	cmd, stdout, stderr, err := startCommand(job, params)
+	if err != nil {
+		return err
 	}
 
-	go io.Copy(os.Stdout, stdout)
-	go io.Copy(os.Stderr, stderr)
+	run := newRunRecord(job.Name, defaultMaxRunOutputSize)
+	runs.add(run)
 
-	return cmd.Wait()
+	inFlight.Add(1)
+	defer inFlight.Done()
+	registerCmd(run.ID, cmd)
+	defer unregisterCmd(run.ID)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); io.Copy(io.MultiWriter(os.Stdout, run), stdout) }()
+	go func() { defer wg.Done(); io.Copy(io.MultiWriter(os.Stderr, run), stderr) }()
+
+	wg.Wait()
+	waitErr := cmd.Wait()
+
+	exitCode := 0
+	if waitErr != nil {
+		if exitError, ok := waitErr.(*exec.ExitError); ok {
+			exitCode = exitError.ExitCode()
+		} else {
+			exitCode = 1
+		}
+	}
+	run.finish(exitCode)
+	runs.persist(run)
+
+	return waitErr
 }
 
 // printHelp prints documentation about environment variables and config files
@@ -152,14 +502,28 @@ Environment Variables:
   EXECUTE_SECRET    : Secret key for authentication (required)
   SHELL_PATH        : Path to the shell used for executing commands (default: /bin/sh)
   LISTEN_PORT       : Port on which the service listens (default: 8080)
+  MAX_RUNS_RETAINED : Number of runs kept in history, oldest evicted first (default: 100)
+  RUNS_PERSIST_DIR  : Directory to persist finished runs to, so history survives a restart (default: none)
+  MAX_CONCURRENT_RUNS : Worker pool size for queued jobs; at most this many run at once (default: 1)
+  SHUTDOWN_GRACE_PERIOD : How long to wait for in-flight commands to finish on SIGTERM/SIGINT before signaling them (default: 20s)
+  SHUTDOWN_KILL_TIMEOUT : How long to wait after signaling commands before killing them outright (default: 5s)
 
 Configuration File (YAML):
-  The configuration file should contain a 'command' key with the shell command to be executed.
+  The configuration file declares a list of named jobs under the 'jobs' key.
 
 Example config.yaml:
-  command: |
-    echo "Hello from Run Command Service!"
-    echo "Current date: $(date)"
+  jobs:
+    - name: hello
+      command: |
+        echo "Hello, ${name}!"
+      args:
+        - name
+    - name: backup
+      command: /usr/local/bin/backup.sh
+      runInBackground: true
+    - name: report
+      command: /usr/local/bin/report.sh
+      queued: true
 
 Usage:
   run-command-service [flags]
@@ -167,10 +531,20 @@ Usage:
 Flags:
   --help    Print this help information
 
+On SIGTERM/SIGINT the service stops accepting new requests, reports 503 on
+/ready, and waits for in-flight commands to finish before exiting.
+
 Endpoints:
-  GET  /ready   : Returns 200 OK if the service is running
-  POST /execute : Executes the configured command and returns its exit code
-                  (requires 'x-secret' header for authentication)
+  GET    /ready           : Returns 200 OK if the service is running, 503 once shutdown has begun
+  GET    /jobs            : Lists the configured jobs
+  POST   /execute/{job}   : Executes the named job and returns its exit code and run ID;
+                            for queued jobs, returns immediately with status "queued"
+                            (requires 'x-secret' header for authentication)
+  GET    /execute/{id}    : Returns a submitted run's status, exit code, and timestamps
+  DELETE /execute/{id}    : Cancels a queued run, or kills it if already running
+  GET    /runs            : Lists tracked runs and their status
+  GET    /runs/{id}       : Returns a run's metadata and captured output
+  GET    /runs/{id}/stream: Streams a run's output live via Server-Sent Events
 
 For more information, please refer to the README.md file.
 `
@@ -184,81 +558,218 @@ func readyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	mu.Lock()
+	down := shuttingDown
+	mu.Unlock()
+
 	w.Header().Set("Content-Type", "application/json")
+	if down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "shutting down"})
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-// executeHandler handles the POST /execute endpoint
+// jobInfo is the JSON representation of a job returned by GET /jobs
+type jobInfo struct {
+	Name            string   `json:"name"`
+	RunInBackground bool     `json:"runInBackground"`
+	RunOnce         bool     `json:"runOnce"`
+	Queued          bool     `json:"queued"`
+	Args            []string `json:"args,omitempty"`
+}
+
+// jobsHandler handles the GET /jobs endpoint
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobs := make([]jobInfo, 0, len(config.Jobs))
+	for _, job := range config.Jobs {
+		jobs = append(jobs, jobInfo{
+			Name:            job.Name,
+			RunInBackground: job.RunInBackground,
+			RunOnce:         job.RunOnce,
+			Queued:          job.Queued,
+			Args:            job.Args,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// executeAcceptedResponse is returned when a background job has been spawned.
+type executeAcceptedResponse struct {
+	Status string `json:"status"`
+	RunID  string `json:"runId"`
+}
+
+// executeResultResponse is returned once a foreground job has finished.
+type executeResultResponse struct {
+	ExitCode int    `json:"exit_code"`
+	RunID    string `json:"runId"`
+}
+
+// executeHandler handles the /execute/ family of endpoints: POST submits a
+// job by name, GET and DELETE target a previously submitted run by ID.
 func executeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+	switch r.Method {
+	case http.MethodPost:
+		executePostHandler(w, r)
+	case http.MethodGet:
+		executeStatusHandler(w, r)
+	case http.MethodDelete:
+		executeCancelHandler(w, r)
+	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// executePostHandler handles the POST /execute/{job} endpoint
+func executePostHandler(w http.ResponseWriter, r *http.Request) {
+	jobName := strings.TrimPrefix(r.URL.Path, "/execute/")
+	if jobName == "" {
+		http.Error(w, "Job name is required", http.StatusBadRequest)
+		return
+	}
+
+	job, ok := jobsByName[jobName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown job %q", jobName), http.StatusNotFound)
 		return
 	}
 
-	// Check for x-secret header
-	secret := r.Header.Get("x-secret")
-	if secret != executeSecret {
+	// Check for x-secret header, falling back to the global secret if the job has none of its own
+	expectedSecret := settings.ExecuteSecret
+	if job.Secret != "" {
+		expectedSecret = job.Secret
+	}
+	if r.Header.Get("x-secret") != expectedSecret {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
+	var params map[string]string
+	if r.Body != nil {
+		defer r.Body.Close()
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err != io.EOF {
+			http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
 
-	// Check if a background process is already running
-	if isRunning && config.RunInBackground {
+	// Hold mu across the check-and-reserve so two concurrent requests for the
+	// same background job can't both pass the check before either sets
+	// runningJobs; the reservation is rolled back below if startCommand fails.
+	mu.Lock()
+	if shuttingDown {
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "service is shutting down"})
+		return
+	}
+	if job.RunInBackground && runningJobs[job.Name] {
+		mu.Unlock()
 		w.WriteHeader(http.StatusConflict)
 		json.NewEncoder(w).Encode(map[string]string{"status": "job still running in background"})
 		return
 	}
-
-	// Expand environment variables in the command
-	expandedCommand := os.ExpandEnv(config.Command)
-
-	// Execute the command using the specified shell
-	cmd := exec.Command(shellPath, "-c", expandedCommand)
-
-	// Set up pipes for stdout and stderr
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating stdout pipe: %v", err), http.StatusInternalServerError)
+	if job.RunInBackground {
+		runningJobs[job.Name] = true
+	}
+	mu.Unlock()
+
+	// Queued jobs are always accepted immediately; the worker pool runs them
+	// FIFO, so there is no "still running" conflict to check for here.
+	if job.Queued {
+		if err := validateParams(job, params); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		run := enqueueTask(job, params)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(executeAcceptedResponse{Status: "queued", RunID: run.ID})
 		return
 	}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating stderr pipe: %v", err), http.StatusInternalServerError)
+	if err := validateParams(job, params); err != nil {
+		if job.RunInBackground {
+			mu.Lock()
+			runningJobs[job.Name] = false
+			mu.Unlock()
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Start the command
-	if err := cmd.Start(); err != nil {
-		http.Error(w, fmt.Sprintf("Error starting command: %v", err), http.StatusInternalServerError)
+	cmd, stdout, stderr, err := startCommand(job, params)
+	if err != nil {
+		if job.RunInBackground {
+			mu.Lock()
+			runningJobs[job.Name] = false
+			mu.Unlock()
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// If running in background, return immediately
-	if config.RunInBackground {
-		isRunning = true
+	// Capture the store in use for this request; it outlives the goroutine below.
+	store := runs
+	run := newRunRecord(job.Name, defaultMaxRunOutputSize)
+	store.add(run)
+
+	// If running in background, return immediately (runningJobs was already
+	// reserved above, before startCommand ran).
+	if job.RunInBackground {
+		inFlight.Add(1)
+		registerCmd(run.ID, cmd)
+
 		go func() {
-			io.Copy(os.Stdout, stdout)
-			io.Copy(os.Stderr, stderr)
-			cmd.Wait()
+			defer inFlight.Done()
+			defer unregisterCmd(run.ID)
+
+			io.Copy(io.MultiWriter(os.Stdout, run), stdout)
+			io.Copy(io.MultiWriter(os.Stderr, run), stderr)
+			waitErr := cmd.Wait()
 			mu.Lock()
-			isRunning = false
+			runningJobs[job.Name] = false
 			mu.Unlock()
+
+			exitCode := 0
+			if waitErr != nil {
+				if exitError, ok := waitErr.(*exec.ExitError); ok {
+					exitCode = exitError.ExitCode()
+				} else {
+					exitCode = 1
+				}
+			}
+			run.finish(exitCode)
+			store.persist(run)
 		}()
 
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "Process spawned successfully"})
+		json.NewEncoder(w).Encode(executeAcceptedResponse{Status: "Process spawned successfully", RunID: run.ID})
 		return
 	}
 
-	// For foreground execution, wait for the command to finish
-	go io.Copy(os.Stdout, stdout)
-	go io.Copy(os.Stderr, stderr)
+	// For foreground execution, wait for the command and its output to finish
+	inFlight.Add(1)
+	defer inFlight.Done()
+	registerCmd(run.ID, cmd)
+	defer unregisterCmd(run.ID)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); io.Copy(io.MultiWriter(os.Stdout, run), stdout) }()
+	go func() { defer wg.Done(); io.Copy(io.MultiWriter(os.Stderr, run), stderr) }()
 
+	wg.Wait()
 	err = cmd.Wait()
 
 	// Prepare the response
@@ -270,6 +781,8 @@ func executeHandler(w http.ResponseWriter, r *http.Request) {
 			exitCode = 1 // Generic error code if we can't determine the actual exit code
 		}
 	}
+	run.finish(exitCode)
+	store.persist(run)
 
 	// Set the appropriate status code based on the exit code
 	if exitCode != 0 {
@@ -279,5 +792,150 @@ func executeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send JSON response with the exit code
-	json.NewEncoder(w).Encode(map[string]int{"exit_code": exitCode})
+	json.NewEncoder(w).Encode(executeResultResponse{ExitCode: exitCode, RunID: run.ID})
+}
+
+// executeStatusHandler handles GET /execute/{id}, polling a queued or
+// otherwise submitted run's status, exit code, and timestamps.
+func executeStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/execute/")
+	run, ok := runs.get(id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown run %q", id), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(run.snapshot())
+}
+
+// executeCancelHandler handles DELETE /execute/{id}: a queued run is pulled
+// from the worker pool via its context, and any running run - queued or
+// plain background/foreground - is killed via its registered *exec.Cmd.
+func executeCancelHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/execute/")
+	run, ok := runs.get(id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown run %q", id), http.StatusNotFound)
+		return
+	}
+
+	switch run.currentStatus() {
+	case RunStatusQueued:
+		if !cancelTask(id) {
+			http.Error(w, fmt.Sprintf("run %q already finished", id), http.StatusConflict)
+			return
+		}
+	case RunStatusRunning:
+		// cancelTask only knows about queued-mode tasks; fall back to killing
+		// the plain background/foreground command registered under this run.
+		if !cancelTask(id) {
+			mu.Lock()
+			cmd, ok := activeCmds[id]
+			mu.Unlock()
+			if !ok {
+				http.Error(w, fmt.Sprintf("run %q already finished", id), http.StatusConflict)
+				return
+			}
+			killCmd(cmd)
+		}
+	default:
+		http.Error(w, fmt.Sprintf("run %q already finished", id), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "canceling"})
+}
+
+// runsListHandler handles the GET /runs endpoint
+func runsListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(runs.list())
+}
+
+// runsItemHandler handles GET /runs/{id} and GET /runs/{id}/stream
+func runsItemHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/runs/")
+	if strings.HasSuffix(path, "/stream") {
+		runStreamHandler(w, r, strings.TrimSuffix(path, "/stream"))
+		return
+	}
+
+	run, ok := runs.get(path)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown run %q", path), http.StatusNotFound)
+		return
+	}
+
+	type runDetail struct {
+		runMeta
+		Output string `json:"output"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(runDetail{runMeta: run.snapshot(), Output: run.outputString()})
+}
+
+// runStreamHandler streams a run's output as Server-Sent Events, replaying
+// what was already captured and then following along until the run finishes.
+func runStreamHandler(w http.ResponseWriter, r *http.Request, id string) {
+	run, ok := runs.get(id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown run %q", id), http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	buffered, ch := run.subscribe()
+	if len(buffered) > 0 {
+		fmt.Fprintf(w, "data: %s\n\n", strings.ReplaceAll(string(buffered), "\n", "\ndata: "))
+		flusher.Flush()
+	}
+
+	if ch != nil {
+		ctx := r.Context()
+	streamLoop:
+		for {
+			select {
+			case chunk, ok := <-ch:
+				if !ok {
+					break streamLoop
+				}
+				fmt.Fprintf(w, "data: %s\n\n", strings.ReplaceAll(string(chunk), "\n", "\ndata: "))
+				flusher.Flush()
+			case <-ctx.Done():
+				run.unsubscribe(ch)
+				return
+			}
+		}
+	}
+
+	final := run.snapshot()
+	fmt.Fprintf(w, "event: end\ndata: %s\n\n", final.Status)
+	flusher.Flush()
 }
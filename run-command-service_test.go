@@ -2,10 +2,15 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -43,35 +48,101 @@ func TestReadyHandler(t *testing.T) {
 	}
 }
 
+func TestReadyHandlerDuringShutdown(t *testing.T) {
+	mu.Lock()
+	shuttingDown = true
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		shuttingDown = false
+		mu.Unlock()
+	}()
+
+	req, err := http.NewRequest("GET", "/ready", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(readyHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
+	}
+}
+
+func TestJobsHandler(t *testing.T) {
+	config = Config{Jobs: []JobConfig{
+		{Name: "greet", Command: "echo 'hi'", Args: []string{"name"}},
+		{Name: "backup", Command: "echo 'backup'", RunInBackground: true},
+	}}
+
+	req, err := http.NewRequest("GET", "/jobs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(jobsHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var got []jobInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(got))
+	}
+	if got[0].Name != "greet" || got[1].Name != "backup" {
+		t.Errorf("unexpected job names: %+v", got)
+	}
+}
+
+func setupJobs(jobs ...JobConfig) {
+	config = Config{Jobs: jobs}
+	jobsByName = make(map[string]*JobConfig, len(jobs))
+	for i := range config.Jobs {
+		jobsByName[config.Jobs[i].Name] = &config.Jobs[i]
+	}
+	mu.Lock()
+	runningJobs = make(map[string]bool)
+	activeCmds = make(map[string]*exec.Cmd)
+	shuttingDown = false
+	mu.Unlock()
+	runs = newRunStore(100, "")
+}
+
 func TestExecuteHandler(t *testing.T) {
-	// Set up test configuration
-	config = Config{Command: "echo 'test'"}
-	executeSecret = "test-secret"
-	shellPath = "/bin/sh"
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
 
 	tests := []struct {
 		name           string
 		method         string
+		job            string
 		secret         string
 		expectedStatus int
 		expectedCode   int
 	}{
-		{"Valid request", "POST", "test-secret", http.StatusOK, 0},
-		{"Invalid method", "GET", "test-secret", http.StatusMethodNotAllowed, 0},
-		{"Invalid secret", "POST", "wrong-secret", http.StatusUnauthorized, 0},
-		{"Failed command", "POST", "test-secret", http.StatusInternalServerError, 1},
+		{"Valid request", "POST", "greet", "test-secret", http.StatusOK, 0},
+		{"Invalid method", "PUT", "greet", "test-secret", http.StatusMethodNotAllowed, 0},
+		{"Invalid secret", "POST", "greet", "wrong-secret", http.StatusUnauthorized, 0},
+		{"Unknown job", "POST", "nope", "test-secret", http.StatusNotFound, 0},
+		{"Failed command", "POST", "fail", "test-secret", http.StatusInternalServerError, 1},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// For the "Failed command" test, temporarily change the command
-			if tt.name == "Failed command" {
-				oldConfig := config
-				config = Config{Command: "exit 1"}
-				defer func() { config = oldConfig }()
-			}
+			setupJobs(
+				JobConfig{Name: "greet", Command: "echo 'test'"},
+				JobConfig{Name: "fail", Command: "exit 1"},
+			)
 
-			req, err := http.NewRequest(tt.method, "/execute", nil)
+			req, err := http.NewRequest(tt.method, "/execute/"+tt.job, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -87,29 +158,104 @@ func TestExecuteHandler(t *testing.T) {
 			}
 
 			if tt.expectedStatus == http.StatusOK || tt.expectedStatus == http.StatusInternalServerError {
-				var response map[string]int
+				var response executeResultResponse
 				err = json.Unmarshal(rr.Body.Bytes(), &response)
 				if err != nil {
 					t.Fatal(err)
 				}
-				if response["exit_code"] != tt.expectedCode {
-					t.Errorf("handler returned unexpected exit code: got %v want %v", response["exit_code"], tt.expectedCode)
+				if response.ExitCode != tt.expectedCode {
+					t.Errorf("handler returned unexpected exit code: got %v want %v", response.ExitCode, tt.expectedCode)
+				}
+				if response.RunID == "" {
+					t.Error("handler did not return a run ID")
+				}
+				if run, ok := runs.get(response.RunID); !ok || run.currentStatus() == RunStatusRunning {
+					t.Errorf("run %s was not recorded as finished", response.RunID)
 				}
 			}
 		})
 	}
 }
 
-func TestExecuteHandlerWithBackgroundOption(t *testing.T) {
-	// Set up test configuration
-	config = Config{
-		Command:         "sleep 2 && echo 'test'",
-		RunInBackground: true,
+func TestExecuteHandlerWithParams(t *testing.T) {
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+	setupJobs(JobConfig{Name: "greet", Command: "echo ${name}", Args: []string{"name"}})
+
+	req, err := http.NewRequest("POST", "/execute/greet", strings.NewReader(`{"name":"world"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("x-secret", "test-secret")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(executeHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+}
+
+// TestExecuteHandlerParamsAreShellQuoted guards against a command-injection
+// regression: a param value containing shell metacharacters must be passed
+// through as a literal value, not interpreted by the shell.
+func TestExecuteHandlerParamsAreShellQuoted(t *testing.T) {
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+
+	dir := t.TempDir()
+	canary := filepath.Join(dir, "PWNED")
+	setupJobs(JobConfig{Name: "greet", Command: "echo ${name}", Args: []string{"name"}})
+
+	body := fmt.Sprintf(`{"name":"hi; touch %s"}`, canary)
+	req, err := http.NewRequest("POST", "/execute/greet", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("x-secret", "test-secret")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(executeHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if _, err := os.Stat(canary); err == nil {
+		t.Fatalf("param value was interpreted by the shell: %s was created", canary)
 	}
-	executeSecret = "test-secret"
-	shellPath = "/bin/sh"
+}
+
+// TestExecuteHandlerRejectsUndeclaredParamsWithNoArgs guards against the
+// allow-list only being enforced when a job declares Args: a job with no
+// Args must reject every param, not silently accept them.
+func TestExecuteHandlerRejectsUndeclaredParamsWithNoArgs(t *testing.T) {
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+	setupJobs(JobConfig{Name: "greet", Command: "echo hi"})
 
-	req, err := http.NewRequest("POST", "/execute", nil)
+	req, err := http.NewRequest("POST", "/execute/greet", strings.NewReader(`{"name":"world"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("x-secret", "test-secret")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(executeHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestExecuteHandlerWithBackgroundOption(t *testing.T) {
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+	setupJobs(JobConfig{Name: "slow", Command: "sleep 2 && echo 'test'", RunInBackground: true})
+
+	req, err := http.NewRequest("POST", "/execute/slow", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -143,11 +289,426 @@ func TestExecuteHandlerWithBackgroundOption(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %v want %v", response["status"], expected)
 	}
 
-	// Wait a bit and check if the output was captured
-	time.Sleep(3 * time.Second)
-	// Note: In a real test environment, you might want to capture os.Stdout
-	// and check its content instead of this comment.
-	// For simplicity, we're just waiting here.
+	// A second request while the job is still running should be rejected
+	req2, _ := http.NewRequest("POST", "/execute/slow", nil)
+	req2.Header.Set("x-secret", "test-secret")
+	rr2 := httptest.NewRecorder()
+	handler.ServeHTTP(rr2, req2)
+	if status := rr2.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+
+	waitForRunToFinish(t, response["runId"], 5*time.Second)
+}
+
+// TestExecuteHandlerWithBackgroundOptionConcurrent guards against a
+// check-then-act race: many concurrent POSTs to the same background job must
+// result in exactly one accepted start, not several.
+func TestExecuteHandlerWithBackgroundOptionConcurrent(t *testing.T) {
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+	setupJobs(JobConfig{Name: "slow", Command: "sleep 1", RunInBackground: true})
+
+	handler := http.HandlerFunc(executeHandler)
+
+	const concurrency = 20
+	codes := make([]int, concurrency)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest("POST", "/execute/slow", nil)
+			req.Header.Set("x-secret", "test-secret")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			codes[i] = rr.Code
+		}(i)
+	}
+	wg.Wait()
+
+	var accepted, conflicted int
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			accepted++
+		case http.StatusConflict:
+			conflicted++
+		default:
+			t.Errorf("unexpected status code: %v", code)
+		}
+	}
+	if accepted != 1 {
+		t.Errorf("expected exactly 1 accepted start, got %d (conflicted: %d)", accepted, conflicted)
+	}
+
+	mu.Lock()
+	running := runningJobs["slow"]
+	mu.Unlock()
+	deadline := time.Now().Add(3 * time.Second)
+	for running && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		running = runningJobs["slow"]
+		mu.Unlock()
+	}
+}
+
+// waitForRunToFinish polls the run store until the given run is no longer
+// running, so later tests don't race against this test's background job.
+func waitForRunToFinish(t *testing.T, runID string, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if run, ok := runs.get(runID); ok {
+			switch run.currentStatus() {
+			case RunStatusQueued, RunStatusRunning:
+			default:
+				return
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("run %s did not finish within %v", runID, timeout)
+}
+
+func TestGracefulShutdownKillsLingeringCommands(t *testing.T) {
+	setupJobs(JobConfig{Name: "stubborn", Command: "trap '' TERM; sleep 5", RunInBackground: true})
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+
+	req, _ := http.NewRequest("POST", "/execute/stubborn", nil)
+	req.Header.Set("x-secret", "test-secret")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("failed to start background job: status %d body %s", rr.Code, rr.Body.String())
+	}
+
+	var response map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.NewServeMux()}
+	done := make(chan struct{})
+	go func() {
+		gracefulShutdown(syscall.SIGTERM, srv, 50*time.Millisecond, 50*time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("gracefulShutdown did not return; command was not killed")
+	}
+
+	waitForRunToFinish(t, response["runId"], 2*time.Second)
+
+	mu.Lock()
+	down := shuttingDown
+	mu.Unlock()
+	if !down {
+		t.Error("expected shuttingDown to be true after gracefulShutdown")
+	}
+	shuttingDown = false
+}
+
+func TestExecuteHandlerWithQueuedOption(t *testing.T) {
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+	settings.MaxConcurrentRuns = 1
+	setupJobs(JobConfig{Name: "report", Command: "echo 'done'", Queued: true})
+	startQueueWorkers(settings.MaxConcurrentRuns)
+
+	req, _ := http.NewRequest("POST", "/execute/report", nil)
+	req.Header.Set("x-secret", "test-secret")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusAccepted)
+	}
+
+	var response executeAcceptedResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != "queued" {
+		t.Errorf("unexpected status: got %v want %v", response.Status, "queued")
+	}
+
+	waitForRunToFinish(t, response.RunID, 5*time.Second)
+
+	run, ok := runs.get(response.RunID)
+	if !ok {
+		t.Fatalf("run %s not found", response.RunID)
+	}
+	if run.currentStatus() != RunStatusSucceeded {
+		t.Errorf("unexpected final status: got %v want %v", run.currentStatus(), RunStatusSucceeded)
+	}
+}
+
+func TestExecuteStatusAndCancelHandlers(t *testing.T) {
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+	settings.MaxConcurrentRuns = 1
+	setupJobs(JobConfig{Name: "slow-queued", Command: "sleep 2", Queued: true})
+	startQueueWorkers(settings.MaxConcurrentRuns)
+
+	postReq, _ := http.NewRequest("POST", "/execute/slow-queued", nil)
+	postReq.Header.Set("x-secret", "test-secret")
+	postRR := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(postRR, postReq)
+
+	var accepted executeAcceptedResponse
+	if err := json.Unmarshal(postRR.Body.Bytes(), &accepted); err != nil {
+		t.Fatal(err)
+	}
+
+	// Poll via GET /execute/{id}
+	getReq, _ := http.NewRequest("GET", "/execute/"+accepted.RunID, nil)
+	getRR := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(getRR, getReq)
+	if getRR.Code != http.StatusOK {
+		t.Fatalf("GET /execute/{id} returned wrong status code: got %v want %v", getRR.Code, http.StatusOK)
+	}
+	var status runMeta
+	if err := json.Unmarshal(getRR.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.ID != accepted.RunID {
+		t.Errorf("unexpected run ID in status: got %v want %v", status.ID, accepted.RunID)
+	}
+
+	// Cancel it while it's running
+	deleteReq, _ := http.NewRequest("DELETE", "/execute/"+accepted.RunID, nil)
+	deleteRR := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(deleteRR, deleteReq)
+	if deleteRR.Code != http.StatusOK {
+		t.Fatalf("DELETE /execute/{id} returned wrong status code: got %v want %v", deleteRR.Code, http.StatusOK)
+	}
+
+	waitForRunToFinish(t, accepted.RunID, 2*time.Second)
+	run, _ := runs.get(accepted.RunID)
+	if run.currentStatus() != RunStatusFailed {
+		t.Errorf("canceled run should be marked failed: got %v", run.currentStatus())
+	}
+
+	// A second DELETE on an already-finished run should be rejected
+	deleteRR2 := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(deleteRR2, deleteReq)
+	if deleteRR2.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", deleteRR2.Code, http.StatusConflict)
+	}
+
+	// Status of an unknown run should 404
+	unknownReq, _ := http.NewRequest("GET", "/execute/does-not-exist", nil)
+	unknownRR := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(unknownRR, unknownReq)
+	if unknownRR.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", unknownRR.Code, http.StatusNotFound)
+	}
+}
+
+// TestExecuteCancelHandlerNonQueuedRun guards against DELETE /execute/{id}
+// only knowing how to cancel queued-mode runs: a plain runInBackground run
+// must be killable too, not misreported as "already finished".
+func TestExecuteCancelHandlerNonQueuedRun(t *testing.T) {
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+	setupJobs(JobConfig{Name: "slow-bg", Command: "sleep 2", RunInBackground: true})
+
+	postReq, _ := http.NewRequest("POST", "/execute/slow-bg", nil)
+	postReq.Header.Set("x-secret", "test-secret")
+	postRR := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(postRR, postReq)
+
+	var accepted executeAcceptedResponse
+	if err := json.Unmarshal(postRR.Body.Bytes(), &accepted); err != nil {
+		t.Fatal(err)
+	}
+
+	deleteReq, _ := http.NewRequest("DELETE", "/execute/"+accepted.RunID, nil)
+	deleteRR := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(deleteRR, deleteReq)
+	if deleteRR.Code != http.StatusOK {
+		t.Fatalf("DELETE /execute/{id} returned wrong status code: got %v want %v, body: %s", deleteRR.Code, http.StatusOK, deleteRR.Body.String())
+	}
+
+	waitForRunToFinish(t, accepted.RunID, 2*time.Second)
+	run, _ := runs.get(accepted.RunID)
+	if run.currentStatus() != RunStatusFailed {
+		t.Errorf("canceled run should be marked failed: got %v", run.currentStatus())
+	}
+}
+
+func TestExecuteCancelBeforeStart(t *testing.T) {
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+	settings.MaxConcurrentRuns = 1
+	// A worker pool of size 1 occupied by a slow job leaves the second
+	// submission sitting in the queue, so we can cancel it before it starts.
+	setupJobs(
+		JobConfig{Name: "occupy", Command: "sleep 1", Queued: true},
+		JobConfig{Name: "never-starts", Command: "echo 'should not run'", Queued: true},
+	)
+	startQueueWorkers(settings.MaxConcurrentRuns)
+
+	occupyReq, _ := http.NewRequest("POST", "/execute/occupy", nil)
+	occupyReq.Header.Set("x-secret", "test-secret")
+	occupyRR := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(occupyRR, occupyReq)
+
+	waitReq, _ := http.NewRequest("POST", "/execute/never-starts", nil)
+	waitReq.Header.Set("x-secret", "test-secret")
+	waitRR := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(waitRR, waitReq)
+
+	var queued executeAcceptedResponse
+	if err := json.Unmarshal(waitRR.Body.Bytes(), &queued); err != nil {
+		t.Fatal(err)
+	}
+	if run, ok := runs.get(queued.RunID); !ok || run.currentStatus() != RunStatusQueued {
+		t.Fatalf("expected run to still be queued, got %v", run.currentStatus())
+	}
+
+	deleteReq, _ := http.NewRequest("DELETE", "/execute/"+queued.RunID, nil)
+	deleteRR := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(deleteRR, deleteReq)
+	if deleteRR.Code != http.StatusOK {
+		t.Fatalf("DELETE /execute/{id} returned wrong status code: got %v want %v", deleteRR.Code, http.StatusOK)
+	}
+
+	waitForRunToFinish(t, queued.RunID, 3*time.Second)
+	run, _ := runs.get(queued.RunID)
+	if run.currentStatus() != RunStatusFailed {
+		t.Errorf("run canceled before start should be marked failed: got %v", run.currentStatus())
+	}
+	if run.snapshot().ExitCode != -1 {
+		t.Errorf("unexpected exit code for canceled run: got %v want -1", run.snapshot().ExitCode)
+	}
+}
+
+func TestRunsHandlers(t *testing.T) {
+	settings.ExecuteSecret = "test-secret"
+	settings.ShellPath = "/bin/sh"
+	setupJobs(JobConfig{Name: "greet", Command: "echo 'hello'"})
+
+	req, err := http.NewRequest("POST", "/execute/greet", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("x-secret", "test-secret")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(executeHandler).ServeHTTP(rr, req)
+
+	var result executeResultResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	// GET /runs should list the run we just created
+	listReq, _ := http.NewRequest("GET", "/runs", nil)
+	listRR := httptest.NewRecorder()
+	http.HandlerFunc(runsListHandler).ServeHTTP(listRR, listReq)
+	var list []runMeta
+	if err := json.Unmarshal(listRR.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != result.RunID {
+		t.Errorf("expected run %s in list, got %+v", result.RunID, list)
+	}
+
+	// GET /runs/{id} should return its captured output
+	itemReq, _ := http.NewRequest("GET", "/runs/"+result.RunID, nil)
+	itemRR := httptest.NewRecorder()
+	http.HandlerFunc(runsItemHandler).ServeHTTP(itemRR, itemReq)
+	if itemRR.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", itemRR.Code, http.StatusOK)
+	}
+	if !strings.Contains(itemRR.Body.String(), "hello") {
+		t.Errorf("expected captured output to contain %q, got %s", "hello", itemRR.Body.String())
+	}
+
+	// GET /runs/{id}/stream replays the captured output for a finished run
+	streamReq, _ := http.NewRequest("GET", "/runs/"+result.RunID+"/stream", nil)
+	streamRR := httptest.NewRecorder()
+	http.HandlerFunc(runsItemHandler).ServeHTTP(streamRR, streamReq)
+	if !strings.Contains(streamRR.Body.String(), "hello") {
+		t.Errorf("expected stream to replay captured output, got %s", streamRR.Body.String())
+	}
+	if !strings.Contains(streamRR.Body.String(), "event: end") {
+		t.Errorf("expected stream to send an end event, got %s", streamRR.Body.String())
+	}
+
+	// GET /runs/{unknown} is a 404
+	missingReq, _ := http.NewRequest("GET", "/runs/does-not-exist", nil)
+	missingRR := httptest.NewRecorder()
+	http.HandlerFunc(runsItemHandler).ServeHTTP(missingRR, missingReq)
+	if missingRR.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", missingRR.Code, http.StatusNotFound)
+	}
+}
+
+// TestRunRecordWriteDropsSlowSubscriber guards against a stream subscriber
+// that stops reading from blocking the run's own output forever: once its
+// buffered channel fills up, Write must drop it instead of stalling.
+func TestRunRecordWriteDropsSlowSubscriber(t *testing.T) {
+	run := newRunRecord("slow-subscriber", defaultMaxRunOutputSize)
+	_, ch := run.subscribe()
+	if ch == nil {
+		t.Fatal("expected a subscriber channel for a running run")
+	}
+
+	// Never drain ch: fill its buffer, then one more write to trigger the drop.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 32; i++ {
+			run.Write([]byte("x"))
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Write blocked on a slow subscriber instead of dropping it")
+	}
+
+	// The dropped channel must eventually be closed, not left dangling.
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for range ch {
+		}
+	}()
+	select {
+	case <-drained:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dropped subscriber channel was never closed")
+	}
+}
+
+// TestRunRecordUnsubscribe guards against a stream client that disconnects:
+// its channel must be removed from the listener list so later Writes don't
+// keep trying to deliver to (and potentially drop) a channel nobody reads.
+func TestRunRecordUnsubscribe(t *testing.T) {
+	run := newRunRecord("disconnecting-subscriber", defaultMaxRunOutputSize)
+	_, ch := run.subscribe()
+	if ch == nil {
+		t.Fatal("expected a subscriber channel for a running run")
+	}
+
+	run.unsubscribe(ch)
+
+	run.mu.Lock()
+	n := len(run.listeners)
+	run.mu.Unlock()
+	if n != 0 {
+		t.Errorf("expected no listeners after unsubscribe, got %d", n)
+	}
 }
 
 func TestRunOnceOption(t *testing.T) {
@@ -161,7 +722,7 @@ func TestRunOnceOption(t *testing.T) {
 	}{
 		{"Successful command", "echo 'test'", true, false, false, ""},
 		{"Failed command", "exit 1", true, false, true, ""},
-		{"Mutually exclusive options", "echo 'test'", true, true, true, "runOnce and runInBackground cannot both be set to true"},
+		{"Mutually exclusive options", "echo 'test'", true, true, true, `job "main": runOnce, runInBackground, and queued are mutually exclusive`},
 	}
 
 	for _, tt := range tests {
@@ -175,11 +736,14 @@ func TestRunOnceOption(t *testing.T) {
 
 			// Create a temporary config file
 			configPath := filepath.Join(tmpDir, "config.yaml")
-			configContent := Config{
-				Command:         tt.command,
-				RunOnce:         tt.runOnce,
-				RunInBackground: tt.runInBackground,
-			}
+			configContent := Config{Jobs: []JobConfig{
+				{
+					Name:            "main",
+					Command:         tt.command,
+					RunOnce:         tt.runOnce,
+					RunInBackground: tt.runInBackground,
+				},
+			}}
 			configData, err := yaml.Marshal(configContent)
 			if err != nil {
 				t.Fatalf("Failed to marshal config: %v", err)
@@ -189,7 +753,7 @@ func TestRunOnceOption(t *testing.T) {
 				t.Fatalf("Failed to write config file: %v", err)
 			}
 
-			// Set the RCS_CONFIG_FILE_PATH environment variable
+			// Set the CONFIG_FILE_PATH environment variable
 			os.Setenv("RCS_CONFIG_FILE_PATH", configPath)
 			defer os.Unsetenv("RCS_CONFIG_FILE_PATH")
 
@@ -197,8 +761,6 @@ func TestRunOnceOption(t *testing.T) {
 			os.Setenv("RCS_EXECUTE_SECRET", "test-secret")
 			defer os.Unsetenv("RCS_EXECUTE_SECRET")
 
-			shellPath = "/bin/sh"
-
 			err = run()
 
 			if (err != nil) != tt.expectedErr {
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RunStatus describes the lifecycle state of a tracked invocation.
+type RunStatus string
+
+const (
+	RunStatusQueued    RunStatus = "queued"
+	RunStatusRunning   RunStatus = "running"
+	RunStatusSucceeded RunStatus = "succeeded"
+	RunStatusFailed    RunStatus = "failed"
+)
+
+// runRecord tracks a single invocation of a job: its metadata, captured
+// stdout/stderr (bounded to a fixed number of bytes, like a ring buffer,
+// so a chatty long-running job can't exhaust memory), and any listeners
+// currently streaming its output live.
+type runRecord struct {
+	ID        string
+	Job       string
+	StartedAt time.Time
+	EndedAt   time.Time
+	Status    RunStatus
+	ExitCode  int
+
+	mu        sync.Mutex
+	output    []byte
+	maxOutput int
+	listeners []chan []byte
+}
+
+func newRunRecord(job string, maxOutput int) *runRecord {
+	return &runRecord{
+		ID:        generateRunID(),
+		Job:       job,
+		StartedAt: time.Now(),
+		Status:    RunStatusRunning,
+		maxOutput: maxOutput,
+	}
+}
+
+func generateRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Write appends captured output, trimming the oldest bytes once maxOutput is
+// exceeded, and fans the chunk out to any live stream subscribers. Sends are
+// non-blocking: a subscriber whose buffer is full is too slow to keep up (or
+// has gone away) and is dropped rather than allowed to stall the command
+// that's producing this output.
+func (r *runRecord) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	r.output = append(r.output, p...)
+	if r.maxOutput > 0 && len(r.output) > r.maxOutput {
+		r.output = r.output[len(r.output)-r.maxOutput:]
+	}
+	listeners := r.listeners
+	r.mu.Unlock()
+
+	if len(listeners) == 0 {
+		return len(p), nil
+	}
+
+	chunk := append([]byte(nil), p...)
+	var slow []chan []byte
+	for _, ch := range listeners {
+		select {
+		case ch <- chunk:
+		default:
+			slow = append(slow, ch)
+		}
+	}
+	if len(slow) > 0 {
+		r.dropListeners(slow)
+	}
+	return len(p), nil
+}
+
+// dropListeners removes and closes listener channels that couldn't keep up
+// with Write, so a stalled subscriber is cut loose instead of blocking.
+func (r *runRecord) dropListeners(dead []chan []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deadSet := make(map[chan []byte]bool, len(dead))
+	for _, ch := range dead {
+		deadSet[ch] = true
+	}
+	kept := r.listeners[:0]
+	for _, ch := range r.listeners {
+		if deadSet[ch] {
+			close(ch)
+		} else {
+			kept = append(kept, ch)
+		}
+	}
+	r.listeners = kept
+}
+
+// unsubscribe removes ch from the listener list without closing it, e.g.
+// when a streaming client disconnects before the run finishes.
+func (r *runRecord) unsubscribe(ch chan []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, l := range r.listeners {
+		if l == ch {
+			r.listeners = append(r.listeners[:i], r.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// finish marks the run as completed and releases any stream subscribers.
+func (r *runRecord) finish(exitCode int) {
+	r.mu.Lock()
+	r.EndedAt = time.Now()
+	r.ExitCode = exitCode
+	if exitCode == 0 {
+		r.Status = RunStatusSucceeded
+	} else {
+		r.Status = RunStatusFailed
+	}
+	listeners := r.listeners
+	r.listeners = nil
+	r.mu.Unlock()
+
+	for _, ch := range listeners {
+		close(ch)
+	}
+}
+
+// subscribe returns the output captured so far plus a channel that receives
+// future chunks. The channel is nil if the run has already finished, since
+// there is nothing left to stream.
+func (r *runRecord) subscribe() (buffered []byte, ch chan []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buffered = append([]byte(nil), r.output...)
+	if r.Status != RunStatusRunning {
+		return buffered, nil
+	}
+	ch = make(chan []byte, 16)
+	r.listeners = append(r.listeners, ch)
+	return buffered, ch
+}
+
+// runMeta is the JSON-serializable, lock-free view of a runRecord used for
+// API responses and on-disk persistence.
+type runMeta struct {
+	ID        string    `json:"id"`
+	Job       string    `json:"job"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt,omitempty"`
+	Status    RunStatus `json:"status"`
+	ExitCode  int       `json:"exitCode"`
+}
+
+// currentStatus returns the run's status under lock.
+func (r *runRecord) currentStatus() RunStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Status
+}
+
+// markQueued marks a run as waiting in the queued-execution worker pool.
+func (r *runRecord) markQueued() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Status = RunStatusQueued
+}
+
+// markRunning transitions a queued run to running, recording the actual
+// start time now that a worker has picked it up.
+func (r *runRecord) markRunning() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.StartedAt = time.Now()
+	r.Status = RunStatusRunning
+}
+
+func (r *runRecord) snapshot() runMeta {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return runMeta{ID: r.ID, Job: r.Job, StartedAt: r.StartedAt, EndedAt: r.EndedAt, Status: r.Status, ExitCode: r.ExitCode}
+}
+
+func (r *runRecord) outputString() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return string(r.output)
+}
+
+// runStore keeps the most recent runs in memory, evicting the oldest once
+// maxRuns is exceeded, and optionally persists finished runs to disk so
+// history survives a restart.
+type runStore struct {
+	mu      sync.Mutex
+	order   []string
+	byID    map[string]*runRecord
+	maxRuns int
+	dir     string
+}
+
+func newRunStore(maxRuns int, dir string) *runStore {
+	return &runStore{
+		byID:    make(map[string]*runRecord),
+		maxRuns: maxRuns,
+		dir:     dir,
+	}
+}
+
+func (s *runStore) add(r *runRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.byID[r.ID] = r
+	s.order = append(s.order, r.ID)
+	for s.maxRuns > 0 && len(s.order) > s.maxRuns {
+		delete(s.byID, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *runStore) get(id string) (*runRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.byID[id]
+	return r, ok
+}
+
+func (s *runStore) list() []runMeta {
+	s.mu.Lock()
+	ids := append([]string(nil), s.order...)
+	s.mu.Unlock()
+
+	snapshots := make([]runMeta, 0, len(ids))
+	for _, id := range ids {
+		if r, ok := s.get(id); ok {
+			snapshots = append(snapshots, r.snapshot())
+		}
+	}
+	return snapshots
+}
+
+// persistedRun is the on-disk representation of a finished run.
+type persistedRun struct {
+	runMeta
+	Output string `json:"output"`
+}
+
+// persist writes a finished run to disk as JSON, keyed by run ID, so it can
+// be recovered with loadFromDisk after a restart.
+func (s *runStore) persist(r *runRecord) {
+	if s.dir == "" {
+		return
+	}
+	data, err := json.Marshal(persistedRun{runMeta: r.snapshot(), Output: r.outputString()})
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(s.dir, 0755)
+	_ = os.WriteFile(filepath.Join(s.dir, r.ID+".json"), data, 0644)
+}
+
+// loadFromDisk restores previously persisted runs into memory, e.g. on startup.
+func (s *runStore) loadFromDisk() {
+	if s.dir == "" {
+		return
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var persisted persistedRun
+		if err := json.Unmarshal(data, &persisted); err != nil {
+			continue
+		}
+		rec := &runRecord{
+			ID:        persisted.ID,
+			Job:       persisted.Job,
+			StartedAt: persisted.StartedAt,
+			EndedAt:   persisted.EndedAt,
+			Status:    persisted.Status,
+			ExitCode:  persisted.ExitCode,
+			output:    []byte(persisted.Output),
+		}
+		s.add(rec)
+	}
+}